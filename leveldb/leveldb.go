@@ -4,6 +4,7 @@ import (
 	"github.com/cosiner/redis-migrate/migrate"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 type levelDB struct {
@@ -41,3 +42,35 @@ func (l levelDB) Iterator() migrate.KeyValueIterator {
 		iter: l.db.NewIterator(nil, nil),
 	}
 }
+
+// PrefixIterator seeks directly to the key range starting with prefix
+// instead of walking the whole database and filtering afterwards.
+func (l levelDB) PrefixIterator(prefix string) migrate.KeyValueIterator {
+	return levelDBIter{
+		iter: l.db.NewIterator(util.BytesPrefix([]byte(prefix)), nil),
+	}
+}
+
+type checkpoint struct {
+	db  *leveldb.DB
+	key []byte
+}
+
+// NewCheckpoint stores migrate.Checkpoint state as a single value under
+// key in db, so a resumable migration can keep its cursor in the same
+// LevelDB instance it's reading from.
+func NewCheckpoint(db *leveldb.DB, key string) migrate.Checkpoint {
+	return checkpoint{db: db, key: []byte(key)}
+}
+
+func (c checkpoint) Load() ([]byte, error) {
+	data, err := c.db.Get(c.key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (c checkpoint) Save(data []byte) error {
+	return c.db.Put(c.key, data, nil)
+}
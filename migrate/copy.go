@@ -3,8 +3,10 @@ package migrate
 import (
 	"bytes"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"os"
+	"sync"
 )
 
 type CopyRecorder interface {
@@ -63,103 +65,224 @@ func (c *stdCopyRecorder) Key(typ SourceKeyType, key string, item []string) {
 	c.flush()
 }
 
+// CopyOptions configures the concurrency of CopyWithOptions.
+type CopyOptions struct {
+	// Workers is the number of goroutines fetching values from src and
+	// writing them to dst. Defaults to 1 (fully serial) if <= 0.
+	Workers int
+	// QueueSize is the number of pending keys buffered per worker.
+	// Defaults to 1 if <= 0.
+	QueueSize int
+}
+
+var defaultCopyOptions = CopyOptions{Workers: 1, QueueSize: 1}
+
+// resumeTracker is implemented by the SourceKeyIterator returned from a
+// Source built with NewResumableSource, so CopyWithOptions can report
+// whether a key it handed to dst actually made it there, letting the
+// resumable iterator checkpoint only confirmed progress instead of keys
+// it merely scanned.
+type resumeTracker interface {
+	keyDone(key string, err error)
+}
+
+// Copy drains src and writes every key into dst, serially, reporting
+// progress and errors through recorder.
 func Copy(src Source, dst Destination, recorder CopyRecorder) {
+	CopyWithOptions(src, dst, recorder, defaultCopyOptions)
+}
+
+// CopyWithOptions is Copy with control over how many keys are fetched
+// and written concurrently. Redis lists, hashes, sets and zsets are
+// order-sensitive for their own key, so every SourceKey is routed by a
+// hash of its name to one of opts.Workers worker goroutines: that keeps
+// all writes for a given key on a single worker and therefore in
+// iterator order, while unrelated keys are copied in parallel.
+func CopyWithOptions(src Source, dst Destination, recorder CopyRecorder, opts CopyOptions) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	queueSize := opts.QueueSize
+	if queueSize <= 0 {
+		queueSize = 1
+	}
+
+	var mu sync.Mutex
+	errorf := func(message string, err error, kvs ...interface{}) {
+		mu.Lock()
+		recorder.Error(message, err, kvs...)
+		mu.Unlock()
+	}
+	keyf := func(typ SourceKeyType, key string, item []string) {
+		mu.Lock()
+		recorder.Key(typ, key, item)
+		mu.Unlock()
+	}
+
 	iter := src.Iterator()
 	defer func() {
 		err := iter.Close()
 		if err != nil {
-			recorder.Error("close source iterator failed", err)
+			errorf("close source iterator failed", err)
 		}
 	}()
 
+	tracker, _ := iter.(resumeTracker)
+
+	queues := make([]chan SourceKey, workers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queue := make(chan SourceKey, queueSize)
+		queues[i] = queue
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range queue {
+				err := copyKey(src, dst, key, errorf, keyf)
+				if tracker != nil {
+					tracker.keyDone(key.Key(), err)
+				}
+			}
+		}()
+	}
+
 	for {
 		key, err := iter.Next()
 		if err != nil {
-			recorder.Error("iterate next key failed", err)
-			continue
+			errorf("iterate next key failed", err)
+			break
 		}
 		if key == nil {
 			break
 		}
+		queues[shardKey(key.Key(), workers)] <- key
+	}
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
 
-		typ, err := key.Type()
-		if err != nil {
-			recorder.Error("retrieve key type failed", err, "key", key.Key())
-			continue
+	err := iter.Error()
+	if err != nil {
+		errorf("iterator errors", err)
+	}
+	recorder.Finish()
+}
+
+// shardKey deterministically maps a key name onto one of n workers so
+// every write for that key always lands on the same worker.
+func shardKey(key string, n int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
+
+// copyKey copies a single key from src to dst, logging every failure it
+// hits through errorf, and returns the first of them so a caller (the
+// resumable iterator, via resumeTracker) can tell whether the key was
+// actually written in full.
+func copyKey(src Source, dst Destination, key SourceKey, errorf func(string, error, ...interface{}), keyf func(SourceKeyType, string, []string)) error {
+	var firstErr error
+	fail := func(message string, err error, kvs ...interface{}) {
+		errorf(message, err, kvs...)
+		if firstErr == nil {
+			firstErr = err
 		}
+	}
+
+	typ, err := key.Type()
+	if err != nil {
+		fail("retrieve key type failed", err, "key", key.Key())
+		return firstErr
+	}
 
-		switch typ {
-		default:
-			recorder.Error("unsupported key type", err, "type", typ, "key", key.Key())
-		case SourceKeyTypeSkip:
-		case SourceKeyTypeString:
-			val, err := src.Get(key)
+	switch typ {
+	default:
+		fail("unsupported key type", fmt.Errorf("unsupported key type %q", typ), "type", typ, "key", key.Key())
+		return firstErr
+	case SourceKeyTypeSkip:
+		return nil
+	case SourceKeyTypeString:
+		val, err := src.Get(key)
+		if err != nil {
+			fail("get string key value failed", err, "type", typ, "key", key.Key())
+		} else {
+			keyf(typ, key.Key(), nil)
+			err = dst.Set(key.Key(), val)
 			if err != nil {
-				recorder.Error("get string key value failed", err, "type", typ, "key", key.Key())
-			} else {
-				recorder.Key(typ, key.Key(), nil)
-				err = dst.Set(key.Key(), val)
-				if err != nil {
-					recorder.Error("set string key value failed", err, "type", typ, "key", key.Key())
-				}
+				fail("set string key value failed", err, "type", typ, "key", key.Key())
 			}
-		case SourceKeyTypeHash:
-			items, err := src.HItems(key)
-			if err != nil {
-				recorder.Error("get hash items failed", err, "type", typ, "key", key.Key())
-			} else {
-				for _, kv := range items {
-					recorder.Key(typ, key.Key(), []string{kv.Key})
-					err = dst.HSet(key.Key(), kv.Key, kv.Value)
-					if err != nil {
-						recorder.Error("set hash item failed", err, "type", typ, "key", key.Key(), "field", kv.Key)
-					}
+		}
+	case SourceKeyTypeHash:
+		items, err := src.HItems(key)
+		if err != nil {
+			fail("get hash items failed", err, "type", typ, "key", key.Key())
+		} else {
+			for _, kv := range items {
+				keyf(typ, key.Key(), []string{kv.Key})
+				err = dst.HSet(key.Key(), kv.Key, kv.Value)
+				if err != nil {
+					fail("set hash item failed", err, "type", typ, "key", key.Key(), "field", kv.Key)
 				}
 			}
-		case SourceKeyTypeList:
-			items, err := src.LItems(key)
-			if err != nil {
-				recorder.Error("get list items failed", err, "type", typ, "key", key.Key())
-			} else {
-				for _, item := range items {
-					recorder.Key(typ, key.Key(), []string{item})
-					err = dst.LPush(key.Key(), item)
-					if err != nil {
-						recorder.Error("push list item failed", err, "type", typ, "key", key.Key(), "item", item)
-					}
+		}
+	case SourceKeyTypeList:
+		items, err := src.LItems(key)
+		if err != nil {
+			fail("get list items failed", err, "type", typ, "key", key.Key())
+		} else {
+			// LItems returns items head-first, but LPush prepends, so
+			// pushing them in that order would reverse the list on dst.
+			// Push tail-first instead, so the last LPush leaves the
+			// original head at the head.
+			for i := len(items) - 1; i >= 0; i-- {
+				item := items[i]
+				keyf(typ, key.Key(), []string{item})
+				err = dst.LPush(key.Key(), item)
+				if err != nil {
+					fail("push list item failed", err, "type", typ, "key", key.Key(), "item", item)
 				}
 			}
-		case SourceKeyTypeSet:
-			members, err := src.SMembers(key)
-			if err != nil {
-				recorder.Error("get set members failed", err, "type", typ, "key", key.Key())
-			} else {
-				for _, member := range members {
-					recorder.Key(typ, key.Key(), []string{member})
-					err = dst.SAdd(key.Key(), member)
-					if err != nil {
-						recorder.Error("add set member failed", err, "type", typ, "key", key.Key(), "member", member)
-					}
+		}
+	case SourceKeyTypeSet:
+		members, err := src.SMembers(key)
+		if err != nil {
+			fail("get set members failed", err, "type", typ, "key", key.Key())
+		} else {
+			for _, member := range members {
+				keyf(typ, key.Key(), []string{member})
+				err = dst.SAdd(key.Key(), member)
+				if err != nil {
+					fail("add set member failed", err, "type", typ, "key", key.Key(), "member", member)
 				}
 			}
-		case SourceKeyTypeZSet:
-			members, err := src.ZMembers(key)
-			if err != nil {
-				recorder.Error("get zset members failed", err, "type", typ, "key", key.Key())
-			} else {
-				for _, member := range members {
-					recorder.Key(typ, key.Key(), []string{member.Key})
-					err = dst.ZAdd(key.Key(), member.Key, member.Score)
-					if err != nil {
-						recorder.Error("add zset member failed", err, "type", typ, "key", key.Key(), "member", member)
-					}
+		}
+	case SourceKeyTypeZSet:
+		members, err := src.ZMembers(key)
+		if err != nil {
+			fail("get zset members failed", err, "type", typ, "key", key.Key())
+		} else {
+			for _, member := range members {
+				keyf(typ, key.Key(), []string{member.Key})
+				err = dst.ZAdd(key.Key(), member.Key, member.Score)
+				if err != nil {
+					fail("add zset member failed", err, "type", typ, "key", key.Key(), "member", member)
 				}
 			}
 		}
 	}
-	err := iter.Error()
+
+	ttl, err := key.TTL()
 	if err != nil {
-		recorder.Error("iterator errors", err)
+		fail("get key ttl failed", err, "type", typ, "key", key.Key())
+		return firstErr
 	}
-	recorder.Finish()
+	if ttl > 0 {
+		if err := dst.Expire(key.Key(), ttl); err != nil {
+			fail("set key expire failed", err, "type", typ, "key", key.Key())
+		}
+	}
+	return firstErr
 }
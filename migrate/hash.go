@@ -0,0 +1,94 @@
+package migrate
+
+import (
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// consistentHashDestination fans writes out across a fixed set of
+// Destinations by consistent-hashing the target key, so for a given
+// dsts list the same key always lands on the same backing Destination.
+// Ring positions are derived from each Destination's index in dsts:
+// appending one moves only the keys that now hash to its virtual nodes,
+// while removing or reordering entries can move keys between the others.
+// Useful when the target isn't a real Redis Cluster but a set of
+// independent standalone instances.
+type consistentHashDestination struct {
+	ring  []uint32
+	nodes map[uint32]Destination
+	dsts  []Destination
+}
+
+const defaultHashReplicas = 160
+
+// NewConsistentHashDestination builds a Destination that routes each
+// write to one of dsts based on a consistent hash of the target key.
+// replicas controls how many virtual nodes are placed on the ring per
+// destination; pass 0 to use a sane default.
+func NewConsistentHashDestination(dsts []Destination, replicas int) (Destination, error) {
+	if len(dsts) == 0 {
+		return nil, fmt.Errorf("consistent hash destination requires at least one destination")
+	}
+	if replicas <= 0 {
+		replicas = defaultHashReplicas
+	}
+
+	h := &consistentHashDestination{
+		nodes: make(map[uint32]Destination, len(dsts)*replicas),
+		dsts:  dsts,
+	}
+	for i, dst := range dsts {
+		for r := 0; r < replicas; r++ {
+			hash := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + "#" + strconv.Itoa(r)))
+			h.ring = append(h.ring, hash)
+			h.nodes[hash] = dst
+		}
+	}
+	sort.Slice(h.ring, func(i, j int) bool { return h.ring[i] < h.ring[j] })
+	return h, nil
+}
+
+func (h *consistentHashDestination) route(key string) Destination {
+	hash := crc32.ChecksumIEEE([]byte(key))
+	idx := sort.Search(len(h.ring), func(i int) bool { return h.ring[i] >= hash })
+	if idx == len(h.ring) {
+		idx = 0
+	}
+	return h.nodes[h.ring[idx]]
+}
+
+func (h *consistentHashDestination) Close() error {
+	var err error
+	for _, dst := range h.dsts {
+		if e := dst.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (h *consistentHashDestination) Set(k string, v []byte) error {
+	return h.route(k).Set(k, v)
+}
+
+func (h *consistentHashDestination) HSet(hk, k string, v []byte) error {
+	return h.route(hk).HSet(hk, k, v)
+}
+
+func (h *consistentHashDestination) SAdd(s, k string) error {
+	return h.route(s).SAdd(s, k)
+}
+
+func (h *consistentHashDestination) ZAdd(z, k string, s float64) error {
+	return h.route(z).ZAdd(z, k, s)
+}
+
+func (h *consistentHashDestination) LPush(l, k string) error {
+	return h.route(l).LPush(l, k)
+}
+
+func (h *consistentHashDestination) Expire(k string, ttlMillis int64) error {
+	return h.route(k).Expire(k, ttlMillis)
+}
@@ -0,0 +1,322 @@
+package migrate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Op identifies which Destination or Source method an Event describes.
+type Op string
+
+const (
+	OpSet      Op = "set"
+	OpHSet     Op = "hset"
+	OpSAdd     Op = "sadd"
+	OpZAdd     Op = "zadd"
+	OpLPush    Op = "lpush"
+	OpExpire   Op = "expire"
+	OpGet      Op = "get"
+	OpHItems   Op = "hitems"
+	OpLItems   Op = "litems"
+	OpSMembers Op = "smembers"
+	OpZMembers Op = "zmembers"
+)
+
+// Event reports the outcome of a single Destination or Source call to
+// an Observer, once it has completed.
+type Event struct {
+	Op  Op
+	Key string
+	// Value is the payload written or read, for a call that has a
+	// single one. It is nil for HItems/LItems/SMembers/ZMembers, which
+	// aggregate multiple items into Bytes instead of reporting one.
+	Value    []byte
+	Bytes    int
+	Duration time.Duration
+	Err      error
+}
+
+// Observer is notified of every call an observed Destination or Source
+// makes, so an operator can see where a slow migration is actually
+// spending its time: source reads, target writes, or specific hot keys.
+type Observer interface {
+	Observe(Event)
+}
+
+type observedDestination struct {
+	dst Destination
+	obs Observer
+}
+
+// NewObservedDestination wraps dst so every call is reported to obs
+// with its key, payload size and how long it took.
+func NewObservedDestination(dst Destination, obs Observer) Destination {
+	return observedDestination{dst: dst, obs: obs}
+}
+
+func (o observedDestination) report(op Op, key string, value []byte, start time.Time, err error) error {
+	o.obs.Observe(Event{Op: op, Key: key, Value: value, Bytes: len(value), Duration: time.Since(start), Err: err})
+	return err
+}
+
+func (o observedDestination) Close() error { return o.dst.Close() }
+
+func (o observedDestination) Set(k string, v []byte) error {
+	start := time.Now()
+	err := o.dst.Set(k, v)
+	return o.report(OpSet, k, v, start, err)
+}
+
+func (o observedDestination) HSet(h, k string, v []byte) error {
+	start := time.Now()
+	err := o.dst.HSet(h, k, v)
+	return o.report(OpHSet, h, v, start, err)
+}
+
+func (o observedDestination) SAdd(s, k string) error {
+	start := time.Now()
+	err := o.dst.SAdd(s, k)
+	return o.report(OpSAdd, s, []byte(k), start, err)
+}
+
+func (o observedDestination) ZAdd(z, k string, s float64) error {
+	start := time.Now()
+	err := o.dst.ZAdd(z, k, s)
+	return o.report(OpZAdd, z, []byte(k), start, err)
+}
+
+func (o observedDestination) LPush(l, k string) error {
+	start := time.Now()
+	err := o.dst.LPush(l, k)
+	return o.report(OpLPush, l, []byte(k), start, err)
+}
+
+func (o observedDestination) Expire(k string, ttlMillis int64) error {
+	start := time.Now()
+	err := o.dst.Expire(k, ttlMillis)
+	return o.report(OpExpire, k, nil, start, err)
+}
+
+type observedSource struct {
+	src Source
+	obs Observer
+}
+
+// NewObservedSource wraps src so every call is reported to obs with its
+// key, payload size and how long it took, mirroring
+// NewObservedDestination on the read side.
+func NewObservedSource(src Source, obs Observer) Source {
+	return observedSource{src: src, obs: obs}
+}
+
+func (o observedSource) report(op Op, key string, bytes int, value []byte, start time.Time, err error) {
+	o.obs.Observe(Event{Op: op, Key: key, Value: value, Bytes: bytes, Duration: time.Since(start), Err: err})
+}
+
+func (o observedSource) Close() error                { return o.src.Close() }
+func (o observedSource) Iterator() SourceKeyIterator { return o.src.Iterator() }
+
+func (o observedSource) Get(k SourceKey) ([]byte, error) {
+	start := time.Now()
+	val, err := o.src.Get(k)
+	o.report(OpGet, k.Key(), len(val), val, start, err)
+	return val, err
+}
+
+func (o observedSource) HItems(k SourceKey) ([]SourceHashItem, error) {
+	start := time.Now()
+	items, err := o.src.HItems(k)
+	n := 0
+	for _, item := range items {
+		n += len(item.Value)
+	}
+	o.report(OpHItems, k.Key(), n, nil, start, err)
+	return items, err
+}
+
+func (o observedSource) LItems(k SourceKey) ([]string, error) {
+	start := time.Now()
+	items, err := o.src.LItems(k)
+	n := 0
+	for _, item := range items {
+		n += len(item)
+	}
+	o.report(OpLItems, k.Key(), n, nil, start, err)
+	return items, err
+}
+
+func (o observedSource) SMembers(k SourceKey) ([]string, error) {
+	start := time.Now()
+	members, err := o.src.SMembers(k)
+	n := 0
+	for _, member := range members {
+		n += len(member)
+	}
+	o.report(OpSMembers, k.Key(), n, nil, start, err)
+	return members, err
+}
+
+func (o observedSource) ZMembers(k SourceKey) ([]SourceZSetMember, error) {
+	start := time.Now()
+	members, err := o.src.ZMembers(k)
+	n := 0
+	for _, member := range members {
+		n += len(member.Key)
+	}
+	o.report(OpZMembers, k.Key(), n, nil, start, err)
+	return members, err
+}
+
+// OpStats accumulates the counters CounterObserver tracks per Op.
+type OpStats struct {
+	Count    int64
+	Errors   int64
+	Bytes    int64
+	Duration time.Duration
+}
+
+// CounterObserver accumulates per-op call counts, error counts, byte
+// totals and cumulative latency, and can render them in Prometheus's
+// text exposition format without depending on a metrics client library.
+type CounterObserver struct {
+	mu    sync.Mutex
+	stats map[Op]*OpStats
+}
+
+// NewCounterObserver returns an empty CounterObserver, ready to Observe.
+func NewCounterObserver() *CounterObserver {
+	return &CounterObserver{stats: make(map[Op]*OpStats)}
+}
+
+func (c *CounterObserver) Observe(e Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.stats[e.Op]
+	if s == nil {
+		s = &OpStats{}
+		c.stats[e.Op] = s
+	}
+	s.Count++
+	s.Bytes += int64(e.Bytes)
+	s.Duration += e.Duration
+	if e.Err != nil {
+		s.Errors++
+	}
+}
+
+// Snapshot returns a point-in-time copy of the counters gathered so far.
+func (c *CounterObserver) Snapshot() map[Op]OpStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := make(map[Op]OpStats, len(c.stats))
+	for op, s := range c.stats {
+		out[op] = *s
+	}
+	return out
+}
+
+// WriteTo renders the current counters as Prometheus text-exposition
+// metrics: a counter and an error counter per op, plus a gauge for the
+// mean latency observed so far.
+func (c *CounterObserver) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	buf.WriteString("# TYPE redis_migrate_ops_total counter\n")
+	buf.WriteString("# TYPE redis_migrate_op_errors_total counter\n")
+	buf.WriteString("# TYPE redis_migrate_op_bytes_total counter\n")
+	buf.WriteString("# TYPE redis_migrate_op_duration_seconds_mean gauge\n")
+
+	for op, s := range c.Snapshot() {
+		fmt.Fprintf(&buf, "redis_migrate_ops_total{op=%q} %d\n", op, s.Count)
+		fmt.Fprintf(&buf, "redis_migrate_op_errors_total{op=%q} %d\n", op, s.Errors)
+		fmt.Fprintf(&buf, "redis_migrate_op_bytes_total{op=%q} %d\n", op, s.Bytes)
+		mean := 0.0
+		if s.Count > 0 {
+			mean = s.Duration.Seconds() / float64(s.Count)
+		}
+		fmt.Fprintf(&buf, "redis_migrate_op_duration_seconds_mean{op=%q} %f\n", op, mean)
+	}
+
+	n, err := buf.WriteTo(w)
+	return n, err
+}
+
+// DebugObserver writes one human-readable line per Event to out. Keys
+// that contain non-printable bytes are split into printable runs and
+// hex-encoded runs, the way binary payloads are rendered in other KV
+// libraries' debug logs, so they stay legible instead of corrupting the
+// terminal.
+type DebugObserver struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	out io.Writer
+}
+
+// NewDebugObserver returns a DebugObserver writing to out. A nil out
+// defaults to os.Stdout, the same as NewStdCopyRecorder.
+func NewDebugObserver(out io.Writer) *DebugObserver {
+	if out == nil {
+		out = os.Stdout
+	}
+	return &DebugObserver{out: out}
+}
+
+// debugValueMax caps how many bytes of an Event's Value DebugObserver
+// prints, so one oversized payload doesn't flood the log.
+const debugValueMax = 256
+
+func (d *DebugObserver) Observe(e Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.buf.Reset()
+	fmt.Fprintf(&d.buf, "%s key=%s bytes=%d dur=%s", e.Op, printableOrHex(e.Key), e.Bytes, e.Duration)
+	if len(e.Value) > 0 {
+		value := e.Value
+		truncated := len(value) > debugValueMax
+		if truncated {
+			value = value[:debugValueMax]
+		}
+		fmt.Fprintf(&d.buf, " value=%s", printableOrHex(string(value)))
+		if truncated {
+			d.buf.WriteString("...")
+		}
+	}
+	if e.Err != nil {
+		fmt.Fprintf(&d.buf, " err=%s", e.Err)
+	}
+	d.buf.WriteString("\n")
+	_, _ = d.buf.WriteTo(d.out)
+}
+
+// printableOrHex returns s unchanged if every byte in it is printable
+// ASCII, otherwise renders it as alternating printable and \xHH-escaped
+// runs so the non-printable parts can still be told apart in a log.
+func printableOrHex(s string) string {
+	printable := true
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] > 0x7e {
+			printable = false
+			break
+		}
+	}
+	if printable {
+		return s
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c > 0x7e {
+			fmt.Fprintf(&buf, "\\x%02x", c)
+		} else {
+			buf.WriteByte(c)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,51 @@
+package migrate
+
+import "strings"
+
+// PrefixIterable is implemented by a Source that can push a key prefix
+// down into how it iterates - a MATCH pattern on a redis SCAN, or a
+// key-range seek on an on-disk index - instead of visiting every key
+// and filtering afterwards the way NewKeyPatternSource does.
+type PrefixIterable interface {
+	PrefixIterator(prefix string) SourceKeyIterator
+}
+
+type prefixSource struct {
+	Source
+	prefix string
+}
+
+// PrefixSource restricts src to the keys starting with prefix. If src
+// implements PrefixIterable the prefix is pushed down to it; otherwise
+// PrefixSource falls back to filtering src's full iteration, which is
+// still correct but costs a pass over every key.
+func PrefixSource(src Source, prefix string) Source {
+	return prefixSource{Source: src, prefix: prefix}
+}
+
+func (s prefixSource) Iterator() SourceKeyIterator {
+	if pi, ok := s.Source.(PrefixIterable); ok {
+		return pi.PrefixIterator(s.prefix)
+	}
+	return &prefixFilterIterator{
+		SourceKeyIterator: s.Source.Iterator(),
+		prefix:            s.prefix,
+	}
+}
+
+type prefixFilterIterator struct {
+	SourceKeyIterator
+	prefix string
+}
+
+func (it *prefixFilterIterator) Next() (SourceKey, error) {
+	for {
+		key, err := it.SourceKeyIterator.Next()
+		if err != nil || key == nil {
+			return key, err
+		}
+		if strings.HasPrefix(key.Key(), it.prefix) {
+			return key, nil
+		}
+	}
+}
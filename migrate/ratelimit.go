@@ -0,0 +1,210 @@
+package migrate
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// bucket is a simple lock-protected token bucket, refilled by elapsed
+// wall-clock time rather than a background goroutine/ticker.
+type bucket struct {
+	mu sync.Mutex
+
+	rate     float64 // tokens per second, 0 means unlimited
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newBucket(rate int) *bucket {
+	if rate <= 0 {
+		return nil
+	}
+	return &bucket{
+		rate:     float64(rate),
+		capacity: float64(rate),
+		tokens:   float64(rate),
+		last:     time.Now(),
+	}
+}
+
+// wait blocks until n tokens are available. Each sleep for the current
+// deficit also selects on ctx, so cancellation is observed promptly
+// instead of only once the sleep has ended.
+// n is capped at the bucket's capacity, so a single request bigger than
+// the bucket can ever hold still drains it and proceeds instead of
+// waiting forever for a token count the bucket can never reach.
+func (b *bucket) wait(ctx context.Context, n int) error {
+	if b == nil || n <= 0 {
+		return nil
+	}
+
+	need := float64(n)
+	if need > b.capacity {
+		need = b.capacity
+	}
+
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+
+		if b.tokens >= need {
+			b.tokens -= need
+			b.mu.Unlock()
+			return nil
+		}
+		deficit := need - b.tokens
+		b.mu.Unlock()
+
+		timer := time.NewTimer(time.Duration(deficit / b.rate * float64(time.Second)))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+type rateLimitedDestination struct {
+	ctx   context.Context
+	dst   Destination
+	ops   *bucket
+	bytes *bucket
+}
+
+// NewRateLimitedDestination wraps dst so that every Set/HSet/SAdd/ZAdd/
+// LPush call is throttled to at most opsPerSec operations per second.
+// If bytesPerSec is > 0, Set and HSet are additionally throttled by the
+// size of the value being written. A limit of 0 disables that limit.
+// ctx is waited on during every throttle sleep, so cancelling it unblocks a
+// caller stuck behind a tight rate limit; a nil ctx behaves as
+// context.Background.
+func NewRateLimitedDestination(ctx context.Context, dst Destination, opsPerSec int, bytesPerSec int) Destination {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return rateLimitedDestination{
+		ctx:   ctx,
+		dst:   dst,
+		ops:   newBucket(opsPerSec),
+		bytes: newBucket(bytesPerSec),
+	}
+}
+
+func (r rateLimitedDestination) Close() error { return r.dst.Close() }
+
+func (r rateLimitedDestination) Set(k string, v []byte) error {
+	if err := r.ops.wait(r.ctx, 1); err != nil {
+		return err
+	}
+	if err := r.bytes.wait(r.ctx, len(v)); err != nil {
+		return err
+	}
+	return r.dst.Set(k, v)
+}
+
+func (r rateLimitedDestination) HSet(h, k string, v []byte) error {
+	if err := r.ops.wait(r.ctx, 1); err != nil {
+		return err
+	}
+	if err := r.bytes.wait(r.ctx, len(v)); err != nil {
+		return err
+	}
+	return r.dst.HSet(h, k, v)
+}
+
+func (r rateLimitedDestination) SAdd(s, k string) error {
+	if err := r.ops.wait(r.ctx, 1); err != nil {
+		return err
+	}
+	return r.dst.SAdd(s, k)
+}
+
+func (r rateLimitedDestination) ZAdd(z, k string, s float64) error {
+	if err := r.ops.wait(r.ctx, 1); err != nil {
+		return err
+	}
+	return r.dst.ZAdd(z, k, s)
+}
+
+func (r rateLimitedDestination) LPush(l, k string) error {
+	if err := r.ops.wait(r.ctx, 1); err != nil {
+		return err
+	}
+	return r.dst.LPush(l, k)
+}
+
+func (r rateLimitedDestination) Expire(k string, ttlMillis int64) error {
+	if err := r.ops.wait(r.ctx, 1); err != nil {
+		return err
+	}
+	return r.dst.Expire(k, ttlMillis)
+}
+
+type rateLimitedSource struct {
+	ctx context.Context
+	src Source
+	ops *bucket
+}
+
+// NewRateLimitedSource wraps src so that every HItems/LItems/SMembers/
+// ZMembers call is throttled to at most opsPerSec operations per
+// second, bounding the read pressure placed on a live source cluster.
+// ctx is waited on during every throttle sleep, so cancelling it unblocks a
+// caller stuck behind a tight rate limit; a nil ctx behaves as
+// context.Background.
+func NewRateLimitedSource(ctx context.Context, src Source, opsPerSec int) Source {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return rateLimitedSource{
+		ctx: ctx,
+		src: src,
+		ops: newBucket(opsPerSec),
+	}
+}
+
+func (r rateLimitedSource) Close() error                { return r.src.Close() }
+func (r rateLimitedSource) Iterator() SourceKeyIterator { return r.src.Iterator() }
+
+func (r rateLimitedSource) Get(k SourceKey) ([]byte, error) {
+	if err := r.ops.wait(r.ctx, 1); err != nil {
+		return nil, err
+	}
+	return r.src.Get(k)
+}
+
+func (r rateLimitedSource) HItems(k SourceKey) ([]SourceHashItem, error) {
+	if err := r.ops.wait(r.ctx, 1); err != nil {
+		return nil, err
+	}
+	return r.src.HItems(k)
+}
+
+func (r rateLimitedSource) LItems(k SourceKey) ([]string, error) {
+	if err := r.ops.wait(r.ctx, 1); err != nil {
+		return nil, err
+	}
+	return r.src.LItems(k)
+}
+
+func (r rateLimitedSource) SMembers(k SourceKey) ([]string, error) {
+	if err := r.ops.wait(r.ctx, 1); err != nil {
+		return nil, err
+	}
+	return r.src.SMembers(k)
+}
+
+func (r rateLimitedSource) ZMembers(k SourceKey) ([]SourceZSetMember, error) {
+	if err := r.ops.wait(r.ctx, 1); err != nil {
+		return nil, err
+	}
+	return r.src.ZMembers(k)
+}
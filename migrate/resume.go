@@ -0,0 +1,272 @@
+package migrate
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+)
+
+// Checkpoint persists opaque progress markers for a resumable
+// migration so it can pick up where it left off after a crash or a
+// deliberate restart.
+type Checkpoint interface {
+	// Load returns the last saved state, or nil if none has been saved
+	// yet.
+	Load() ([]byte, error)
+	Save([]byte) error
+}
+
+type fileCheckpoint struct {
+	path string
+}
+
+// FileCheckpoint stores checkpoint state in the file at path, rewriting
+// it atomically (write-then-rename) on every Save.
+func FileCheckpoint(path string) Checkpoint {
+	return fileCheckpoint{path: path}
+}
+
+func (f fileCheckpoint) Load() ([]byte, error) {
+	data, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (f fileCheckpoint) Save(data []byte) error {
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
+
+// ResumableIterator is implemented by a SourceKeyIterator that can
+// describe and restore its own scan position. NewResumableSource uses
+// it to save progress into a Checkpoint as iteration proceeds, and to
+// resume from it on the next run.
+type ResumableIterator interface {
+	// Cursor returns an opaque token that Seek can later use to resume
+	// scanning from the start of the batch the most recently returned
+	// key came from.
+	Cursor() ([]byte, error)
+	// Seek restores scanning to a cursor token previously returned by
+	// Cursor.
+	Seek(cursor []byte) error
+}
+
+// ErrResumeKeyNotFound is returned by a resumed iterator's Next when the
+// checkpointed key is never seen again during the replay scan - for
+// example because it expired or was deleted on the source between runs.
+// Silently finishing in that case would look like a complete migration
+// while having copied nothing; callers should treat it as fatal and
+// investigate rather than resume blind.
+var ErrResumeKeyNotFound = errors.New("migrate: checkpointed key not found while resuming scan")
+
+// checkpointEvery controls how many confirmed keys pass through a
+// resumable iterator between checkpoint saves.
+const checkpointEvery = 100
+
+// maxPendingKeys bounds how many keys a resumableIterator will hand out
+// of Next without a keyDone report before Next blocks. Without a bound,
+// a destination write that hangs - or a key CopyWithOptions never
+// reports back on - would make the iterator buffer every key scanned
+// for the rest of the run, unbounded. This is independent of checkpoint
+// advancement: a permanently failing key still blocks the checkpoint
+// forever by design, but its pending slot is freed as soon as its
+// outcome is known, so it alone can't wedge Next.
+const maxPendingKeys = 10000
+
+type checkpointState struct {
+	Cursor  []byte
+	LastKey string
+}
+
+type resumableSource struct {
+	src Source
+	cp  Checkpoint
+}
+
+// NewResumableSource wraps src so that, if its SourceKeyIterator also
+// implements ResumableIterator, scan progress is saved into cp as keys
+// are confirmed written to the destination (CopyWithOptions reports
+// confirmations back through the SourceKeyIterator it returns), and
+// restored from cp the next time Iterator is called. Because the
+// underlying cursor can only resume from the start of a batch, the
+// wrapper also remembers the last key it checkpointed and replays
+// (skipping already-seen keys) up to that point, so a restart never
+// drops a key that was scanned but not yet confirmed copied.
+func NewResumableSource(src Source, cp Checkpoint) Source {
+	return resumableSource{src: src, cp: cp}
+}
+
+func (r resumableSource) Close() error { return r.src.Close() }
+
+func (r resumableSource) Get(k SourceKey) ([]byte, error) { return r.src.Get(k) }
+func (r resumableSource) HItems(k SourceKey) ([]SourceHashItem, error) {
+	return r.src.HItems(k)
+}
+func (r resumableSource) LItems(k SourceKey) ([]string, error)   { return r.src.LItems(k) }
+func (r resumableSource) SMembers(k SourceKey) ([]string, error) { return r.src.SMembers(k) }
+func (r resumableSource) ZMembers(k SourceKey) ([]SourceZSetMember, error) {
+	return r.src.ZMembers(k)
+}
+
+func (r resumableSource) Iterator() SourceKeyIterator {
+	iter := r.src.Iterator()
+	ri, ok := iter.(ResumableIterator)
+	if !ok {
+		return &resumableIterator{SourceKeyIterator: iter}
+	}
+
+	it := &resumableIterator{SourceKeyIterator: iter, ri: ri, cp: r.cp, pending: make(chan struct{}, maxPendingKeys)}
+
+	saved, err := r.cp.Load()
+	if err != nil || len(saved) == 0 {
+		return it
+	}
+	var state checkpointState
+	if err := json.Unmarshal(saved, &state); err != nil {
+		return it
+	}
+	if err := ri.Seek(state.Cursor); err != nil {
+		return it
+	}
+	it.skipUntil = state.LastKey
+	return it
+}
+
+type pendingKey struct {
+	key    string
+	cursor []byte
+}
+
+// resumableIterator checkpoints progress as keys it returned are
+// confirmed written, and replays-and-skips up to a previously
+// checkpointed key right after a resume.
+//
+// Copy's worker pool writes the keys Next hands out concurrently, so
+// they're confirmed in whatever order the workers happen to finish, not
+// the order Next returned them. keyDone (called by CopyWithOptions
+// through the resumeTracker interface once a key's write settles)
+// buffers completions in order and only ever checkpoints the longest
+// confirmed prefix: a failed key is never skipped past, so it
+// permanently stops further checkpoint progress for this run rather
+// than risk a resume silently skipping a key that was never actually
+// copied.
+type resumableIterator struct {
+	SourceKeyIterator
+	ri ResumableIterator
+	cp Checkpoint
+
+	skipUntil string
+
+	// pending caps the number of keys handed out of Next but not yet
+	// reported back through keyDone at maxPendingKeys; track acquires a
+	// slot before adding to order and keyDone releases one the first
+	// time it sees an outcome for a key, regardless of whether that
+	// outcome lets checkpoint advancement pop the key off order.
+	pending chan struct{}
+
+	mu      sync.Mutex
+	order   []pendingKey
+	outcome map[string]error
+	unsaved int
+}
+
+func (it *resumableIterator) Next() (SourceKey, error) {
+	for {
+		key, err := it.SourceKeyIterator.Next()
+		if err != nil {
+			return key, err
+		}
+		if key == nil {
+			if it.skipUntil != "" {
+				return nil, ErrResumeKeyNotFound
+			}
+			return nil, nil
+		}
+		if it.skipUntil != "" {
+			if key.Key() == it.skipUntil {
+				it.skipUntil = ""
+			}
+			continue
+		}
+
+		if it.ri != nil {
+			it.track(key.Key())
+		}
+		return key, nil
+	}
+}
+
+// track records that key was handed out of Next, alongside the cursor
+// for the batch it came from, so it can later be checkpointed once
+// confirmed written. It blocks once maxPendingKeys keys are already
+// awaiting a keyDone report.
+func (it *resumableIterator) track(key string) {
+	cursor, err := it.ri.Cursor()
+	if err != nil {
+		return
+	}
+
+	it.pending <- struct{}{}
+
+	it.mu.Lock()
+	it.order = append(it.order, pendingKey{key: key, cursor: cursor})
+	it.mu.Unlock()
+}
+
+// keyDone makes resumableIterator satisfy the resumeTracker interface
+// CopyWithOptions uses to report whether a key it returned was actually
+// written to the destination.
+func (it *resumableIterator) keyDone(key string, err error) {
+	if it.ri == nil {
+		return
+	}
+
+	it.mu.Lock()
+	defer it.mu.Unlock()
+
+	if it.outcome == nil {
+		it.outcome = make(map[string]error)
+	}
+	if _, recorded := it.outcome[key]; !recorded {
+		// The key's write has settled, success or failure, so it's no
+		// longer in flight: release its pending slot here rather than
+		// when it's popped off order below, or a single permanently
+		// failing key - which by design blocks checkpoint advancement
+		// forever - would also wedge track() once maxPendingKeys more
+		// keys had been scanned behind it.
+		<-it.pending
+	}
+	it.outcome[key] = err
+
+	var advanced *pendingKey
+	for len(it.order) > 0 {
+		head := it.order[0]
+		outcome, ok := it.outcome[head.key]
+		if !ok {
+			break
+		}
+		if outcome != nil {
+			break
+		}
+		delete(it.outcome, head.key)
+		it.order = it.order[1:]
+		advanced = &head
+		it.unsaved++
+	}
+
+	if advanced == nil || it.unsaved < checkpointEvery {
+		return
+	}
+	it.unsaved = 0
+	data, err := json.Marshal(checkpointState{Cursor: advanced.cursor, LastKey: advanced.key})
+	if err != nil {
+		return
+	}
+	_ = it.cp.Save(data)
+}
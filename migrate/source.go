@@ -18,6 +18,9 @@ const (
 type SourceKey interface {
 	Key() string
 	Type() (SourceKeyType, error)
+	// TTL returns the key's remaining time to live in milliseconds, or
+	// a value <= 0 if the key has no expiry.
+	TTL() (int64, error)
 }
 
 type SourceKeyIterator interface {
@@ -148,10 +151,24 @@ type KeyValueDB interface {
 	Iterator() KeyValueIterator
 }
 
+// PrefixIterableDB is implemented by a KeyValueDB that can push a key
+// prefix down to a real key-range seek instead of scanning everything
+// and filtering afterwards. It mirrors PrefixIterable on Source and is
+// kept optional for the same reason: requiring it on KeyValueDB itself
+// would break any external implementer that only ever supports a full
+// scan.
+type PrefixIterableDB interface {
+	PrefixIterator(prefix string) KeyValueIterator
+}
+
 type KeyValueItem struct {
 	Key  string
 	Type SourceKeyType
 
+	// TTLMillis is the key's remaining time to live in milliseconds, or
+	// <= 0 if the key has no expiry.
+	TTLMillis int64
+
 	StringValue []byte
 
 	HashField      string
@@ -197,6 +214,10 @@ func (k keyValueSourceKey) Type() (SourceKeyType, error) {
 	return k.item.Type, nil
 }
 
+func (k keyValueSourceKey) TTL() (int64, error) {
+	return k.item.TTLMillis, nil
+}
+
 type keyValueIterator struct {
 	iter   KeyValueIterator
 	parser SourceKeyParser
@@ -242,6 +263,26 @@ func (s keyValueSource) Iterator() SourceKeyIterator {
 	}
 }
 
+// PrefixIterator makes keyValueSource satisfy PrefixIterable. If db
+// implements PrefixIterableDB the prefix is pushed down to its own
+// range seek; otherwise it falls back to filtering a full scan, the
+// same fallback PrefixSource itself uses for a Source that can't push
+// prefixes down.
+func (s keyValueSource) PrefixIterator(prefix string) SourceKeyIterator {
+	pi, ok := s.db.(PrefixIterableDB)
+	if !ok {
+		return &prefixFilterIterator{
+			SourceKeyIterator: s.Iterator(),
+			prefix:            prefix,
+		}
+	}
+	iter := pi.PrefixIterator(prefix)
+	return &keyValueIterator{
+		iter:   iter,
+		parser: s.parser,
+	}
+}
+
 func (s keyValueSource) Get(k SourceKey) ([]byte, error) {
 	item, _ := k.(keyValueSourceKey)
 	return item.item.StringValue, nil
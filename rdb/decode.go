@@ -0,0 +1,504 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// reader is the minimal interface the decoders below need: a plain
+// io.Reader for bulk copies plus ReadByte for the single-byte opcode
+// and length-prefix fields RDB is built from.
+type reader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// RDB opcodes, as written by SAVE/BGSAVE. See the (informal) RDB format
+// spec for the authoritative list; we only decode what's needed to walk
+// the keyspace and read back string/list/hash/set/zset values.
+const (
+	opAux      = 0xFA
+	opResizeDB = 0xFB
+	opExpireMS = 0xFC
+	opExpire   = 0xFD
+	opSelectDB = 0xFE
+	opEOF      = 0xFF
+)
+
+// value type opcodes
+const (
+	typeString         = 0
+	typeList           = 1
+	typeSet            = 2
+	typeZSet           = 3
+	typeHash           = 4
+	typeZSet2          = 5
+	typeHashZipmap     = 9
+	typeListZiplist    = 10
+	typeSetIntset      = 11
+	typeZSetZiplist    = 12
+	typeHashZiplist    = 13
+	typeListQuicklist  = 14
+	typeHashListpack   = 16
+	typeZSetListpack   = 17
+	typeListQuicklist2 = 18
+)
+
+// length-encoding marker bits, from the two high bits of the first byte.
+const (
+	lenEncoding6Bit      = 0
+	lenEncoding14Bit     = 1
+	lenEncoding32or64Bit = 2
+	lenEncodingSpecial   = 3
+)
+
+const (
+	encInt8  = 0
+	encInt16 = 1
+	encInt32 = 2
+	encLZF   = 3
+)
+
+// readLength reads an RDB length-encoded integer. isEncoded reports
+// whether the length is actually a special "encoding" value (used by
+// readString to pick an integer/LZF decoding instead of a plain byte
+// string of that length).
+func readLength(r reader) (length uint64, isEncoded bool, err error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch (b & 0xC0) >> 6 {
+	case lenEncoding6Bit:
+		return uint64(b & 0x3F), false, nil
+	case lenEncoding14Bit:
+		b2, err := r.ReadByte()
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(b&0x3F)<<8 | uint64(b2), false, nil
+	case lenEncoding32or64Bit:
+		if b&0x3F == 0 {
+			var v uint32
+			if err := binary.Read(r, binary.BigEndian, &v); err != nil {
+				return 0, false, err
+			}
+			return uint64(v), false, nil
+		}
+		var v uint64
+		if err := binary.Read(r, binary.BigEndian, &v); err != nil {
+			return 0, false, err
+		}
+		return v, false, nil
+	default: // lenEncodingSpecial
+		return uint64(b & 0x3F), true, nil
+	}
+}
+
+// readString reads an RDB string object: either a length-prefixed raw
+// byte string, a small integer packed into the length header, or an
+// LZF-compressed blob.
+func readString(r reader) ([]byte, error) {
+	length, encoded, err := readLength(r)
+	if err != nil {
+		return nil, err
+	}
+	if !encoded {
+		buf := make([]byte, length)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf, nil
+	}
+
+	switch length {
+	case encInt8:
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		return []byte(fmt.Sprintf("%d", int8(b))), nil
+	case encInt16:
+		var v int16
+		if err := binary.Read(r, binary.LittleEndian, &v); err != nil {
+			return nil, err
+		}
+		return []byte(fmt.Sprintf("%d", v)), nil
+	case encInt32:
+		var v int32
+		if err := binary.Read(r, binary.LittleEndian, &v); err != nil {
+			return nil, err
+		}
+		return []byte(fmt.Sprintf("%d", v)), nil
+	case encLZF:
+		compLen, _, err := readLength(r)
+		if err != nil {
+			return nil, err
+		}
+		rawLen, _, err := readLength(r)
+		if err != nil {
+			return nil, err
+		}
+		comp := make([]byte, compLen)
+		if _, err := io.ReadFull(r, comp); err != nil {
+			return nil, err
+		}
+		return lzfDecompress(comp, int(rawLen))
+	default:
+		return nil, fmt.Errorf("rdb: unsupported string encoding %d", length)
+	}
+}
+
+// lzfDecompress inflates the small, simple LZF variant Redis uses for
+// compressed strings within an RDB file.
+func lzfDecompress(in []byte, outLen int) ([]byte, error) {
+	out := make([]byte, 0, outLen)
+	for i := 0; i < len(in); {
+		ctrl := int(in[i])
+		i++
+		if ctrl < 32 {
+			length := ctrl + 1
+			if i+length > len(in) {
+				return nil, fmt.Errorf("rdb: corrupt lzf literal run")
+			}
+			out = append(out, in[i:i+length]...)
+			i += length
+			continue
+		}
+
+		length := ctrl >> 5
+		if length == 7 {
+			if i >= len(in) {
+				return nil, fmt.Errorf("rdb: corrupt lzf back reference")
+			}
+			length += int(in[i])
+			i++
+		}
+		if i >= len(in) {
+			return nil, fmt.Errorf("rdb: corrupt lzf back reference")
+		}
+		ref := len(out) - ((ctrl&0x1F)<<8 | int(in[i])) - 1
+		i++
+		if ref < 0 {
+			return nil, fmt.Errorf("rdb: corrupt lzf back reference")
+		}
+		for n := 0; n < length+2; n++ {
+			out = append(out, out[ref+n])
+		}
+	}
+	return out, nil
+}
+
+// need reports an error unless blob has at least n more bytes starting
+// at pos, so a truncated or malformed blob returns a decode error
+// instead of panicking on an out-of-range slice.
+func need(blob []byte, pos, n int, what string) error {
+	if pos < 0 || n < 0 || pos+n > len(blob) {
+		return fmt.Errorf("rdb: truncated %s", what)
+	}
+	return nil
+}
+
+// ziplistEntries decodes a complete ziplist blob (the legacy compact
+// encoding shared by small lists, hashes and zsets) into its flat
+// sequence of string entries.
+func ziplistEntries(blob []byte) ([][]byte, error) {
+	if len(blob) < 11 {
+		return nil, fmt.Errorf("rdb: ziplist too short")
+	}
+	pos := 10 // skip zlbytes(4) + zltail(4) + zllen(2)
+
+	var entries [][]byte
+	for pos < len(blob) && blob[pos] != 0xFF {
+		// prevlen
+		if blob[pos] < 254 {
+			pos++
+		} else {
+			pos += 5
+		}
+		if pos >= len(blob) {
+			return nil, fmt.Errorf("rdb: truncated ziplist entry")
+		}
+
+		enc := blob[pos]
+		switch {
+		case enc>>6 == 0: // 6-bit length string
+			l := int(enc & 0x3F)
+			pos++
+			if err := need(blob, pos, l, "ziplist entry"); err != nil {
+				return nil, err
+			}
+			entries = append(entries, blob[pos:pos+l])
+			pos += l
+		case enc>>6 == 1: // 14-bit length string
+			if err := need(blob, pos, 2, "ziplist entry"); err != nil {
+				return nil, err
+			}
+			l := int(enc&0x3F)<<8 | int(blob[pos+1])
+			pos += 2
+			if err := need(blob, pos, l, "ziplist entry"); err != nil {
+				return nil, err
+			}
+			entries = append(entries, blob[pos:pos+l])
+			pos += l
+		case enc == 0x80: // 32-bit length string
+			if err := need(blob, pos, 5, "ziplist entry"); err != nil {
+				return nil, err
+			}
+			l := int(binary.BigEndian.Uint32(blob[pos+1 : pos+5]))
+			pos += 5
+			if err := need(blob, pos, l, "ziplist entry"); err != nil {
+				return nil, err
+			}
+			entries = append(entries, blob[pos:pos+l])
+			pos += l
+		default: // integer encodings
+			var val int64
+			var n int
+			switch enc {
+			case 0xC0:
+				if err := need(blob, pos, 3, "ziplist entry"); err != nil {
+					return nil, err
+				}
+				val, n = int64(int16(binary.LittleEndian.Uint16(blob[pos+1:pos+3]))), 2
+			case 0xD0:
+				if err := need(blob, pos, 5, "ziplist entry"); err != nil {
+					return nil, err
+				}
+				val, n = int64(int32(binary.LittleEndian.Uint32(blob[pos+1:pos+5]))), 4
+			case 0xE0:
+				if err := need(blob, pos, 9, "ziplist entry"); err != nil {
+					return nil, err
+				}
+				val, n = int64(binary.LittleEndian.Uint64(blob[pos+1:pos+9])), 8
+			case 0xF0:
+				if err := need(blob, pos, 4, "ziplist entry"); err != nil {
+					return nil, err
+				}
+				b := append([]byte{}, blob[pos+1:pos+4]...)
+				if b[2]&0x80 != 0 {
+					b = append(b, 0xFF)
+				} else {
+					b = append(b, 0)
+				}
+				val, n = int64(int32(binary.LittleEndian.Uint32(b))), 3
+			case 0xFE:
+				if err := need(blob, pos, 2, "ziplist entry"); err != nil {
+					return nil, err
+				}
+				val, n = int64(int8(blob[pos+1])), 1
+			default:
+				if enc >= 0xF1 && enc <= 0xFD {
+					val, n = int64(enc&0x0F)-1, 0
+				} else {
+					return nil, fmt.Errorf("rdb: unsupported ziplist entry encoding 0x%x", enc)
+				}
+			}
+			pos += 1 + n
+			entries = append(entries, []byte(fmt.Sprintf("%d", val)))
+		}
+	}
+	return entries, nil
+}
+
+// listpackBacklenSize returns how many trailing bytes a listpack entry
+// of the given header+data length spends on its backward-length field.
+func listpackBacklenSize(n int) int {
+	switch {
+	case n <= 127:
+		return 1
+	case n <= 16383:
+		return 2
+	case n <= 2097151:
+		return 3
+	case n <= 268435455:
+		return 4
+	default:
+		return 5
+	}
+}
+
+// listpackEntries decodes a complete listpack blob (the encoding that
+// has replaced ziplist for hashes/zsets, and quicklist2's PACKED nodes,
+// since Redis 7) into its flat sequence of string entries.
+func listpackEntries(blob []byte) ([][]byte, error) {
+	if len(blob) < 7 {
+		return nil, fmt.Errorf("rdb: listpack too short")
+	}
+	pos := 6 // skip total-bytes(4) + num-elements(2)
+
+	var entries [][]byte
+	for pos < len(blob) && blob[pos] != 0xFF {
+		start := pos
+		b := blob[pos]
+
+		var val []byte
+		var headerLen, dataLen int
+		switch {
+		case b&0x80 == 0: // 7-bit uint
+			headerLen, dataLen = 1, 0
+			val = []byte(fmt.Sprintf("%d", b&0x7F))
+		case b&0xC0 == 0x80: // 6-bit length string
+			l := int(b & 0x3F)
+			if err := need(blob, pos, 1+l, "listpack entry"); err != nil {
+				return nil, err
+			}
+			headerLen, dataLen = 1, l
+			val = append([]byte{}, blob[pos+1:pos+1+l]...)
+		case b&0xE0 == 0xC0: // 13-bit signed int
+			if err := need(blob, pos, 2, "listpack entry"); err != nil {
+				return nil, err
+			}
+			raw := int32(b&0x1F)<<8 | int32(blob[pos+1])
+			if raw >= 1<<12 {
+				raw -= 1 << 13
+			}
+			headerLen, dataLen = 2, 0
+			val = []byte(fmt.Sprintf("%d", raw))
+		case b&0xF0 == 0xE0: // 12-bit length string
+			if err := need(blob, pos, 2, "listpack entry"); err != nil {
+				return nil, err
+			}
+			l := int(b&0x0F)<<8 | int(blob[pos+1])
+			if err := need(blob, pos, 2+l, "listpack entry"); err != nil {
+				return nil, err
+			}
+			headerLen, dataLen = 2, l
+			val = append([]byte{}, blob[pos+2:pos+2+l]...)
+		case b == 0xF1: // 16-bit signed int
+			if err := need(blob, pos, 3, "listpack entry"); err != nil {
+				return nil, err
+			}
+			v := int16(binary.LittleEndian.Uint16(blob[pos+1 : pos+3]))
+			headerLen, dataLen = 1, 2
+			val = []byte(fmt.Sprintf("%d", v))
+		case b == 0xF2: // 24-bit signed int
+			if err := need(blob, pos, 4, "listpack entry"); err != nil {
+				return nil, err
+			}
+			ext := append([]byte{}, blob[pos+1:pos+4]...)
+			if ext[2]&0x80 != 0 {
+				ext = append(ext, 0xFF)
+			} else {
+				ext = append(ext, 0)
+			}
+			v := int32(binary.LittleEndian.Uint32(ext))
+			headerLen, dataLen = 1, 3
+			val = []byte(fmt.Sprintf("%d", v))
+		case b == 0xF3: // 32-bit signed int
+			if err := need(blob, pos, 5, "listpack entry"); err != nil {
+				return nil, err
+			}
+			v := int32(binary.LittleEndian.Uint32(blob[pos+1 : pos+5]))
+			headerLen, dataLen = 1, 4
+			val = []byte(fmt.Sprintf("%d", v))
+		case b == 0xF4: // 64-bit signed int
+			if err := need(blob, pos, 9, "listpack entry"); err != nil {
+				return nil, err
+			}
+			v := int64(binary.LittleEndian.Uint64(blob[pos+1 : pos+9]))
+			headerLen, dataLen = 1, 8
+			val = []byte(fmt.Sprintf("%d", v))
+		case b == 0xF0: // 32-bit length string
+			if err := need(blob, pos, 5, "listpack entry"); err != nil {
+				return nil, err
+			}
+			l := int(binary.LittleEndian.Uint32(blob[pos+1 : pos+5]))
+			if err := need(blob, pos, 5+l, "listpack entry"); err != nil {
+				return nil, err
+			}
+			headerLen, dataLen = 5, l
+			val = append([]byte{}, blob[pos+5:pos+5+l]...)
+		default:
+			return nil, fmt.Errorf("rdb: unsupported listpack entry encoding 0x%x", b)
+		}
+
+		entries = append(entries, val)
+		pos = start + headerLen + dataLen + listpackBacklenSize(headerLen+dataLen)
+	}
+	return entries, nil
+}
+
+// readOldZSetScore reads the legacy (type 3) zset score encoding: a
+// length byte, either a special marker for -inf/+inf/nan or the count
+// of following ASCII digits.
+func readOldZSetScore(r reader) (float64, error) {
+	lengthByte, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	switch lengthByte {
+	case 255:
+		return math.Inf(-1), nil
+	case 254:
+		return math.Inf(1), nil
+	case 253:
+		return math.NaN(), nil
+	default:
+		buf := make([]byte, lengthByte)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return 0, err
+		}
+		return strconv.ParseFloat(string(buf), 64)
+	}
+}
+
+// readBinaryZSetScore reads the type-5 (ZSET2) score encoding: a raw
+// little-endian IEEE-754 double.
+func readBinaryZSetScore(r reader) (float64, error) {
+	var bits uint64
+	if err := binary.Read(r, binary.LittleEndian, &bits); err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(bits), nil
+}
+
+// intsetEntries decodes a Redis intset blob into its decimal string
+// members.
+func intsetEntries(blob []byte) ([][]byte, error) {
+	if len(blob) < 8 {
+		return nil, fmt.Errorf("rdb: intset too short")
+	}
+	encoding := binary.LittleEndian.Uint32(blob[0:4])
+	length := binary.LittleEndian.Uint32(blob[4:8])
+	// Every element is at least 2 bytes, so blob can't possibly hold
+	// more than (len(blob)-8)/2 of them; reject an oversized length here
+	// rather than handing an attacker-controlled count straight to make.
+	if uint64(length) > uint64(len(blob)-8)/2 {
+		return nil, fmt.Errorf("rdb: truncated intset")
+	}
+
+	entries := make([][]byte, 0, length)
+	pos := 8
+	for i := uint32(0); i < length; i++ {
+		var val int64
+		switch encoding {
+		case 2:
+			if err := need(blob, pos, 2, "intset entry"); err != nil {
+				return nil, err
+			}
+			val = int64(int16(binary.LittleEndian.Uint16(blob[pos : pos+2])))
+			pos += 2
+		case 4:
+			if err := need(blob, pos, 4, "intset entry"); err != nil {
+				return nil, err
+			}
+			val = int64(int32(binary.LittleEndian.Uint32(blob[pos : pos+4])))
+			pos += 4
+		case 8:
+			if err := need(blob, pos, 8, "intset entry"); err != nil {
+				return nil, err
+			}
+			val = int64(binary.LittleEndian.Uint64(blob[pos : pos+8]))
+			pos += 8
+		default:
+			return nil, fmt.Errorf("rdb: unsupported intset encoding %d", encoding)
+		}
+		entries = append(entries, []byte(fmt.Sprintf("%d", val)))
+	}
+	return entries, nil
+}
@@ -0,0 +1,95 @@
+package rdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestZiplistEntries(t *testing.T) {
+	blob := []byte{
+		0, 0, 0, 0, // zlbytes
+		0, 0, 0, 0, // zltail
+		1, 0, // zllen
+		0x00,      // prevlen
+		0x02,      // 6-bit length string, len=2
+		'h', 'i', // data
+		0xFF, // terminator
+	}
+	entries, err := ziplistEntries(blob)
+	if err != nil {
+		t.Fatalf("ziplistEntries: %v", err)
+	}
+	if len(entries) != 1 || string(entries[0]) != "hi" {
+		t.Fatalf("entries = %v, want [hi]", entries)
+	}
+}
+
+func TestZiplistEntriesTruncated(t *testing.T) {
+	blob := []byte{
+		0, 0, 0, 0,
+		0, 0, 0, 0,
+		1, 0,
+		0x00,
+		0x02, // claims a 2-byte string
+		'h',  // but only one data byte follows
+	}
+	_, err := ziplistEntries(blob)
+	if err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Fatalf("err = %v, want a truncated-entry error", err)
+	}
+}
+
+func TestListpackEntries(t *testing.T) {
+	blob := []byte{
+		0, 0, 0, 0, // total-bytes
+		0, 0, // num-elements
+		0x05, 0x02, // 7-bit uint 5, 1-byte backlen
+		0xFF, // terminator
+	}
+	entries, err := listpackEntries(blob)
+	if err != nil {
+		t.Fatalf("listpackEntries: %v", err)
+	}
+	if len(entries) != 1 || string(entries[0]) != "5" {
+		t.Fatalf("entries = %v, want [5]", entries)
+	}
+}
+
+func TestListpackEntriesTruncated(t *testing.T) {
+	blob := []byte{
+		0, 0, 0, 0,
+		0, 0,
+		0x83, // 6-bit length string claiming 3 bytes, none follow
+	}
+	_, err := listpackEntries(blob)
+	if err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Fatalf("err = %v, want a truncated-entry error", err)
+	}
+}
+
+func TestIntsetEntries(t *testing.T) {
+	blob := []byte{
+		2, 0, 0, 0, // encoding = int16
+		1, 0, 0, 0, // length = 1
+		0x2C, 0x01, // 300, little-endian
+	}
+	entries, err := intsetEntries(blob)
+	if err != nil {
+		t.Fatalf("intsetEntries: %v", err)
+	}
+	if len(entries) != 1 || string(entries[0]) != "300" {
+		t.Fatalf("entries = %v, want [300]", entries)
+	}
+}
+
+func TestIntsetEntriesTruncated(t *testing.T) {
+	blob := []byte{
+		4, 0, 0, 0, // encoding = int32
+		1, 0, 0, 0, // length = 1, needs 4 more bytes
+		0, 0, // only 2 follow
+	}
+	_, err := intsetEntries(blob)
+	if err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Fatalf("err = %v, want a truncated-entry error", err)
+	}
+}
@@ -2,8 +2,12 @@ package redis
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-	"github.com/cosiner/redis_migrate"
+	"github.com/cosiner/redis-migrate/migrate"
 	"github.com/go-redis/redis"
 )
 
@@ -11,7 +15,7 @@ type serverDestination struct {
 	client *redis.Client
 }
 
-func NewServerDestination(client *redis.Client) (redis_migrate.Destination, error) {
+func NewServerDestination(client *redis.Client) (migrate.Destination, error) {
 	return serverDestination{client: client}, nil
 }
 
@@ -44,18 +48,27 @@ func (ss serverDestination) LPush(l, k string) error {
 	return err
 }
 
+func (ss serverDestination) Expire(k string, ttlMillis int64) error {
+	return expire(ss.client, k, ttlMillis)
+}
+
+func expire(c redis.Cmdable, key string, ttlMillis int64) error {
+	_, err := c.PExpire(key, time.Duration(ttlMillis)*time.Millisecond).Result()
+	return err
+}
+
 type serverSource struct {
 	client *redis.Client
 }
 
-func NewServerSource(client *redis.Client) (redis_migrate.Source, error) {
+func NewServerSource(client *redis.Client) (migrate.Source, error) {
 	return serverSource{
 		client: client,
 	}, nil
 }
 
 type serverSourceKey struct {
-	s   *redis.Client
+	s   redis.Cmdable
 	key string
 }
 
@@ -63,37 +76,60 @@ func (s serverSourceKey) Key() string {
 	return s.key
 }
 
-func (s serverSourceKey) Type() (redis_migrate.SourceKeyType, error) {
-	typ, err := s.s.Type(s.key).Result()
+func (s serverSourceKey) Type() (migrate.SourceKeyType, error) {
+	return keyType(s.s, s.key)
+}
+
+func (s serverSourceKey) TTL() (int64, error) {
+	return ttl(s.s, s.key)
+}
+
+func ttl(c redis.Cmdable, key string) (int64, error) {
+	d, err := c.PTTL(key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// PTTL reports -1 for a key with no expiry and -2 for a missing
+	// key; neither is a TTL to preserve on the destination.
+	if d < 0 {
+		return 0, nil
+	}
+	return int64(d / time.Millisecond), nil
+}
+
+func keyType(c redis.Cmdable, key string) (migrate.SourceKeyType, error) {
+	typ, err := c.Type(key).Result()
 	if err != nil {
 		return "", err
 	}
 	switch typ {
 	case "string":
-		return redis_migrate.SourceKeyTypeString, nil
+		return migrate.SourceKeyTypeString, nil
 	case "list":
-		return redis_migrate.SourceKeyTypeList, nil
+		return migrate.SourceKeyTypeList, nil
 	case "hash":
-		return redis_migrate.SourceKeyTypeHash, nil
+		return migrate.SourceKeyTypeHash, nil
 	case "set":
-		return redis_migrate.SourceKeyTypeSet, nil
+		return migrate.SourceKeyTypeSet, nil
 	case "zset":
-		return redis_migrate.SourceKeyTypeZSet, nil
+		return migrate.SourceKeyTypeZSet, nil
 	default:
-		return "", fmt.Errorf("unsupported key type %s, %s", s.key, typ)
+		return "", fmt.Errorf("unsupported key type %s, %s", key, typ)
 	}
 }
 
 func (ss serverSource) Close() error { return ss.client.Close() }
 
 type serverKeyIterator struct {
-	s   *redis.Client
-	err error
+	s     *redis.Client
+	match string
+	err   error
 
-	cursor   uint64
-	finished bool
+	cursor      uint64 // cursor to use for the next SCAN call
+	batchCursor uint64 // cursor that produced the currently buffered batch
+	finished    bool
 
-	keys []redis_migrate.SourceKey
+	keys []migrate.SourceKey
 	idx  int
 }
 
@@ -103,7 +139,8 @@ func (ss *serverKeyIterator) fetch() {
 	}
 
 	const BUFSIZE = 1024
-	keys, cursor, err := ss.s.Scan(ss.cursor, "*", BUFSIZE).Result()
+	ss.batchCursor = ss.cursor
+	keys, cursor, err := ss.s.Scan(ss.cursor, ss.match, BUFSIZE).Result()
 	if err != nil {
 		ss.finished = true
 		ss.err = err
@@ -112,7 +149,7 @@ func (ss *serverKeyIterator) fetch() {
 
 	if len(keys) > 0 {
 		if cap(ss.keys) == 0 {
-			ss.keys = make([]redis_migrate.SourceKey, 0, BUFSIZE)
+			ss.keys = make([]migrate.SourceKey, 0, BUFSIZE)
 		}
 	}
 
@@ -126,11 +163,34 @@ func (ss *serverKeyIterator) fetch() {
 	return
 }
 
+// Cursor and Seek implement migrate.ResumableIterator. Cursor reports
+// the SCAN cursor that produced the batch the caller is currently
+// iterating, not the one that will be used next: resuming from it
+// re-fetches that whole batch so a resumableIterator can skip forward
+// to the last key it actually checkpointed instead of losing whatever
+// came after it in the batch.
+func (ss *serverKeyIterator) Cursor() ([]byte, error) {
+	return []byte(strconv.FormatUint(ss.batchCursor, 10)), nil
+}
+
+func (ss *serverKeyIterator) Seek(cursor []byte) error {
+	n, err := strconv.ParseUint(string(cursor), 10, 64)
+	if err != nil {
+		return err
+	}
+	ss.cursor = n
+	ss.keys = ss.keys[:0]
+	ss.idx = 0
+	ss.finished = false
+	ss.err = nil
+	return nil
+}
+
 func (ss *serverKeyIterator) Error() error {
 	return ss.err
 }
 
-func (ss *serverKeyIterator) Next() (redis_migrate.SourceKey, error) {
+func (ss *serverKeyIterator) Next() (migrate.SourceKey, error) {
 	if !ss.finished && ss.idx >= len(ss.keys) {
 		ss.fetch()
 	}
@@ -146,42 +206,69 @@ func (ss *serverKeyIterator) Close() error {
 	return nil
 }
 
-func (ss serverSource) Iterator() redis_migrate.SourceKeyIterator {
+func (ss serverSource) Iterator() migrate.SourceKeyIterator {
+	return &serverKeyIterator{
+		s:     ss.client,
+		match: "*",
+	}
+}
+
+// PrefixIterator makes serverSource satisfy migrate.PrefixIterable,
+// translating prefix into a MATCH prefix* argument on SCAN instead of
+// fetching every key and filtering client-side. prefix is glob-escaped
+// first, since SCAN's MATCH takes a glob pattern and a key prefix
+// containing *, ?, [ or ] is otherwise interpreted as a wildcard
+// instead of matched literally.
+func (ss serverSource) PrefixIterator(prefix string) migrate.SourceKeyIterator {
 	return &serverKeyIterator{
-		s:      ss.client,
-		cursor: 0,
+		s:     ss.client,
+		match: escapeGlob(prefix) + "*",
 	}
 }
 
-func (ss serverSource) allowNil(err error) error {
+// escapeGlob backslash-escapes the characters SCAN's MATCH pattern
+// treats specially (\, *, ?, [, ]) so prefix is matched as literal text.
+func escapeGlob(prefix string) string {
+	var buf strings.Builder
+	for _, r := range prefix {
+		switch r {
+		case '\\', '*', '?', '[', ']':
+			buf.WriteByte('\\')
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+func allowNil(err error) error {
 	if err == redis.Nil {
 		return nil
 	}
 	return err
 }
 
-func (ss serverSource) Get(k redis_migrate.SourceKey) ([]byte, error) {
-	val, err := ss.client.Get(k.Key()).Result()
-	return []byte(val), ss.allowNil(err)
+func get(c redis.Cmdable, key string) ([]byte, error) {
+	val, err := c.Get(key).Result()
+	return []byte(val), allowNil(err)
 }
 
-func (ss serverSource) HItems(k redis_migrate.SourceKey) ([]redis_migrate.SourceHashItem, error) {
-	kvs, err := ss.client.HGetAll(k.Key()).Result()
-	err = ss.allowNil(err)
+func hItems(c redis.Cmdable, key string) ([]migrate.SourceHashItem, error) {
+	kvs, err := c.HGetAll(key).Result()
+	err = allowNil(err)
 	if err != nil {
 		return nil, err
 	}
 
-	items := make([]redis_migrate.SourceHashItem, 0, len(kvs))
+	items := make([]migrate.SourceHashItem, 0, len(kvs))
 	for k, v := range kvs {
-		items = append(items, redis_migrate.SourceHashItem{Key: k, Value: []byte(v)})
+		items = append(items, migrate.SourceHashItem{Key: k, Value: []byte(v)})
 	}
 	return items, nil
 }
 
-func (ss serverSource) LItems(k redis_migrate.SourceKey) ([]string, error) {
-	keys, err := ss.client.LRange(k.Key(), 0, -1).Result()
-	err = ss.allowNil(err)
+func lItems(c redis.Cmdable, key string) ([]string, error) {
+	keys, err := c.LRange(key, 0, -1).Result()
+	err = allowNil(err)
 	if err != nil {
 		return nil, err
 	}
@@ -189,9 +276,9 @@ func (ss serverSource) LItems(k redis_migrate.SourceKey) ([]string, error) {
 	return keys, nil
 }
 
-func (ss serverSource) SMembers(k redis_migrate.SourceKey) ([]string, error) {
-	keys, err := ss.client.SMembers(k.Key()).Result()
-	err = ss.allowNil(err)
+func sMembers(c redis.Cmdable, key string) ([]string, error) {
+	keys, err := c.SMembers(key).Result()
+	err = allowNil(err)
 	if err != nil {
 		return nil, err
 	}
@@ -199,15 +286,15 @@ func (ss serverSource) SMembers(k redis_migrate.SourceKey) ([]string, error) {
 	return keys, nil
 }
 
-func (ss serverSource) ZMembers(k redis_migrate.SourceKey) ([]redis_migrate.SourceZSetMember, error) {
-	items, err := ss.client.ZRangeWithScores(k.Key(), 0, -1).Result()
-	err = ss.allowNil(err)
+func zMembers(c redis.Cmdable, key string) ([]migrate.SourceZSetMember, error) {
+	items, err := c.ZRangeWithScores(key, 0, -1).Result()
+	err = allowNil(err)
 	if err != nil {
 		return nil, err
 	}
-	members := make([]redis_migrate.SourceZSetMember, 0, len(items))
+	members := make([]migrate.SourceZSetMember, 0, len(items))
 	for _, item := range items {
-		members = append(members, redis_migrate.SourceZSetMember{
+		members = append(members, migrate.SourceZSetMember{
 			Score: item.Score,
 			Key:   fmt.Sprint(item.Member),
 		})
@@ -216,6 +303,26 @@ func (ss serverSource) ZMembers(k redis_migrate.SourceKey) ([]redis_migrate.Sour
 	return members, nil
 }
 
+func (ss serverSource) Get(k migrate.SourceKey) ([]byte, error) {
+	return get(ss.client, k.Key())
+}
+
+func (ss serverSource) HItems(k migrate.SourceKey) ([]migrate.SourceHashItem, error) {
+	return hItems(ss.client, k.Key())
+}
+
+func (ss serverSource) LItems(k migrate.SourceKey) ([]string, error) {
+	return lItems(ss.client, k.Key())
+}
+
+func (ss serverSource) SMembers(k migrate.SourceKey) ([]string, error) {
+	return sMembers(ss.client, k.Key())
+}
+
+func (ss serverSource) ZMembers(k migrate.SourceKey) ([]migrate.SourceZSetMember, error) {
+	return zMembers(ss.client, k.Key())
+}
+
 func NewRedisClient(addr, password string) (*redis.Client, error) {
 	client := redis.NewClient(&redis.Options{
 		Network:  "tcp",
@@ -229,3 +336,184 @@ func NewRedisClient(addr, password string) (*redis.Client, error) {
 	}
 	return client, nil
 }
+
+// clusterDestination routes every write straight through the cluster
+// client. Every operation here already targets a single key (the hash,
+// list, set or zset name), so go-redis's own slot routing keeps these
+// commands hash-tag-safe without any extra work on our side.
+type clusterDestination struct {
+	client *redis.ClusterClient
+}
+
+func NewClusterDestination(client *redis.ClusterClient) (migrate.Destination, error) {
+	return clusterDestination{client: client}, nil
+}
+
+func (cd clusterDestination) Close() error {
+	return cd.client.Close()
+}
+
+func (cd clusterDestination) Set(k string, v []byte) error {
+	_, err := cd.client.Set(k, v, 0).Result()
+	return err
+}
+
+func (cd clusterDestination) HSet(h, k string, v []byte) error {
+	_, err := cd.client.HSet(h, k, v).Result()
+	return err
+}
+
+func (cd clusterDestination) SAdd(s, k string) error {
+	_, err := cd.client.SAdd(s, k).Result()
+	return err
+}
+
+func (cd clusterDestination) ZAdd(z, k string, s float64) error {
+	_, err := cd.client.ZAdd(z, redis.Z{Score: s, Member: k}).Result()
+	return err
+}
+
+func (cd clusterDestination) LPush(l, k string) error {
+	_, err := cd.client.LPush(l, k).Result()
+	return err
+}
+
+func (cd clusterDestination) Expire(k string, ttlMillis int64) error {
+	return expire(cd.client, k, ttlMillis)
+}
+
+// clusterSource scans a redis cluster for keys. A single cursor can't
+// walk a cluster's keyspace the way it can a standalone server, so
+// instead we keep one independent SCAN cursor per master node and
+// merge their output as it arrives.
+type clusterSource struct {
+	client *redis.ClusterClient
+}
+
+func NewClusterSource(client *redis.ClusterClient) (migrate.Source, error) {
+	return clusterSource{client: client}, nil
+}
+
+func (cs clusterSource) Close() error { return cs.client.Close() }
+
+type clusterScanResult struct {
+	key migrate.SourceKey
+	err error
+}
+
+// clusterKeyIterator merges the per-master SCAN cursors spawned by
+// clusterSource.Iterator into a single FIFO stream: whichever master
+// finds a key next is the one Next() returns next.
+type clusterKeyIterator struct {
+	results chan clusterScanResult
+	// done is closed by Close to tell the per-master scan goroutines to
+	// stop after their in-flight Scan call, instead of Close draining
+	// results until every master has walked its entire keyspace.
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+func (cs clusterSource) Iterator() migrate.SourceKeyIterator {
+	const BUFSIZE = 1024
+
+	it := &clusterKeyIterator{
+		results: make(chan clusterScanResult, BUFSIZE),
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		defer close(it.results)
+
+		var wg sync.WaitGroup
+		err := cs.client.ForEachMaster(func(master *redis.Client) error {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				var cursor uint64
+				for {
+					select {
+					case <-it.done:
+						return
+					default:
+					}
+
+					keys, next, err := master.Scan(cursor, "*", BUFSIZE).Result()
+					if err != nil {
+						select {
+						case it.results <- clusterScanResult{err: err}:
+						case <-it.done:
+						}
+						return
+					}
+					for _, key := range keys {
+						select {
+						case it.results <- clusterScanResult{key: serverSourceKey{s: master, key: key}}:
+						case <-it.done:
+							return
+						}
+					}
+					if next == 0 {
+						return
+					}
+					cursor = next
+				}
+			}()
+			return nil
+		})
+		if err != nil {
+			select {
+			case it.results <- clusterScanResult{err: err}:
+			case <-it.done:
+			}
+		}
+		wg.Wait()
+	}()
+
+	return it
+}
+
+func (it *clusterKeyIterator) Next() (migrate.SourceKey, error) {
+	if it.err != nil {
+		return nil, nil
+	}
+	res, ok := <-it.results
+	if !ok {
+		return nil, nil
+	}
+	if res.err != nil {
+		it.err = res.err
+		return nil, nil
+	}
+	return res.key, nil
+}
+
+func (it *clusterKeyIterator) Error() error { return it.err }
+
+func (it *clusterKeyIterator) Close() error {
+	it.closeOnce.Do(func() { close(it.done) })
+	for range it.results {
+	}
+	return nil
+}
+
+func (cs clusterSource) Get(k migrate.SourceKey) ([]byte, error) {
+	return get(cs.client, k.Key())
+}
+
+func (cs clusterSource) HItems(k migrate.SourceKey) ([]migrate.SourceHashItem, error) {
+	return hItems(cs.client, k.Key())
+}
+
+func (cs clusterSource) LItems(k migrate.SourceKey) ([]string, error) {
+	return lItems(cs.client, k.Key())
+}
+
+func (cs clusterSource) SMembers(k migrate.SourceKey) ([]string, error) {
+	return sMembers(cs.client, k.Key())
+}
+
+func (cs clusterSource) ZMembers(k migrate.SourceKey) ([]migrate.SourceZSetMember, error) {
+	return zMembers(cs.client, k.Key())
+}
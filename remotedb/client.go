@@ -0,0 +1,112 @@
+package remotedb
+
+import (
+	"context"
+
+	"github.com/cosiner/redis-migrate/migrate"
+	"google.golang.org/grpc"
+)
+
+type remoteDB struct {
+	conn   *grpc.ClientConn
+	client KeyValueDBClient
+}
+
+// NewClient dials the given connection's remote KeyValueDB and returns
+// a migrate.KeyValueDB backed by it, so Copy can run against a store
+// that lives on another host without shipping the store itself over.
+func NewClient(conn *grpc.ClientConn) migrate.KeyValueDB {
+	return remoteDB{
+		conn:   conn,
+		client: NewKeyValueDBClient(conn),
+	}
+}
+
+func (r remoteDB) Close() error {
+	return r.conn.Close()
+}
+
+func (r remoteDB) Iterator() migrate.KeyValueIterator {
+	return r.iterate("")
+}
+
+func (r remoteDB) PrefixIterator(prefix string) migrate.KeyValueIterator {
+	return r.iterate(prefix)
+}
+
+func (r remoteDB) iterate(prefix string) migrate.KeyValueIterator {
+	stream, err := r.client.Iterate(context.Background())
+	if err != nil {
+		return &remoteIterator{err: err}
+	}
+
+	it := &remoteIterator{stream: stream}
+	if err := stream.Send(&NextRequest{Prefix: prefix, BatchSize: defaultBatchSize}); err != nil {
+		it.err = err
+		return it
+	}
+	it.fetch()
+	return it
+}
+
+// remoteIterator buffers one NextResponse batch at a time and asks for
+// the next one as soon as the current one arrives, so Next()
+// rarely blocks on a round trip.
+type remoteIterator struct {
+	stream KeyValueDB_IterateClient
+	err    error
+	items  []*KV
+	idx    int
+	done   bool
+}
+
+func (it *remoteIterator) fetch() {
+	if it.err != nil || it.done {
+		return
+	}
+
+	resp, err := it.stream.Recv()
+	if err != nil {
+		it.err = err
+		return
+	}
+	it.items = resp.Items
+	it.idx = 0
+	if resp.Done {
+		it.done = true
+		_ = it.stream.CloseSend()
+		return
+	}
+	if err := it.stream.Send(&NextRequest{BatchSize: defaultBatchSize}); err != nil {
+		it.err = err
+	}
+}
+
+func (it *remoteIterator) Next() (string, []byte, error) {
+	if it.err != nil {
+		return "", nil, it.err
+	}
+	for it.idx >= len(it.items) {
+		if it.done {
+			return "", nil, nil
+		}
+		it.fetch()
+		if it.err != nil {
+			return "", nil, it.err
+		}
+	}
+
+	item := it.items[it.idx]
+	it.idx++
+	return item.Key, item.Value, nil
+}
+
+// Close ends the client's side of the stream. If the caller stops
+// iterating before it's exhausted, this is what signals the server to
+// release its iterator early.
+func (it *remoteIterator) Close() error {
+	if it.stream == nil {
+		return nil
+	}
+	return it.stream.CloseSend()
+}
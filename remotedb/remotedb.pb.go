@@ -0,0 +1,190 @@
+// remotedb.pb.go is hand-written to match the shape protoc-gen-go's
+// deprecated combined message+service output would have produced for
+// remotedb.proto. It is not reproducible by running the protoc-gen-go
+// this repo actually resolves against (that output split into separate
+// _pb2.go/_grpc.pb.go-style files well before v1.31/v1.58), so treat
+// this file as regular source: edit it directly when remotedb.proto
+// changes, rather than trying to regenerate it.
+// source: remotedb.proto
+
+package remotedb
+
+import (
+	context "context"
+
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+)
+
+type NextRequest struct {
+	Prefix    string `protobuf:"bytes,1,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	BatchSize int32  `protobuf:"varint,2,opt,name=batch_size,json=batchSize,proto3" json:"batch_size,omitempty"`
+}
+
+func (m *NextRequest) Reset()         { *m = NextRequest{} }
+func (m *NextRequest) String() string { return proto.CompactTextString(m) }
+func (*NextRequest) ProtoMessage()    {}
+
+func (m *NextRequest) GetPrefix() string {
+	if m != nil {
+		return m.Prefix
+	}
+	return ""
+}
+
+func (m *NextRequest) GetBatchSize() int32 {
+	if m != nil {
+		return m.BatchSize
+	}
+	return 0
+}
+
+type NextResponse struct {
+	Items []*KV `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	Done  bool  `protobuf:"varint,2,opt,name=done,proto3" json:"done,omitempty"`
+}
+
+func (m *NextResponse) Reset()         { *m = NextResponse{} }
+func (m *NextResponse) String() string { return proto.CompactTextString(m) }
+func (*NextResponse) ProtoMessage()    {}
+
+func (m *NextResponse) GetItems() []*KV {
+	if m != nil {
+		return m.Items
+	}
+	return nil
+}
+
+func (m *NextResponse) GetDone() bool {
+	if m != nil {
+		return m.Done
+	}
+	return false
+}
+
+type KV struct {
+	Key   string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *KV) Reset()         { *m = KV{} }
+func (m *KV) String() string { return proto.CompactTextString(m) }
+func (*KV) ProtoMessage()    {}
+
+func (m *KV) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *KV) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*NextRequest)(nil), "remotedb.NextRequest")
+	proto.RegisterType((*NextResponse)(nil), "remotedb.NextResponse")
+	proto.RegisterType((*KV)(nil), "remotedb.KV")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// KeyValueDBClient is the client API for the KeyValueDB service.
+type KeyValueDBClient interface {
+	Iterate(ctx context.Context, opts ...grpc.CallOption) (KeyValueDB_IterateClient, error)
+}
+
+type keyValueDBClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewKeyValueDBClient(cc *grpc.ClientConn) KeyValueDBClient {
+	return &keyValueDBClient{cc}
+}
+
+func (c *keyValueDBClient) Iterate(ctx context.Context, opts ...grpc.CallOption) (KeyValueDB_IterateClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_KeyValueDB_serviceDesc.Streams[0], "/remotedb.KeyValueDB/Iterate", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &keyValueDBIterateClient{stream}
+	return x, nil
+}
+
+type KeyValueDB_IterateClient interface {
+	Send(*NextRequest) error
+	Recv() (*NextResponse, error)
+	grpc.ClientStream
+}
+
+type keyValueDBIterateClient struct {
+	grpc.ClientStream
+}
+
+func (x *keyValueDBIterateClient) Send(m *NextRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *keyValueDBIterateClient) Recv() (*NextResponse, error) {
+	m := new(NextResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// KeyValueDBServer is the server API for the KeyValueDB service.
+type KeyValueDBServer interface {
+	Iterate(KeyValueDB_IterateServer) error
+}
+
+type KeyValueDB_IterateServer interface {
+	Send(*NextResponse) error
+	Recv() (*NextRequest, error)
+	grpc.ServerStream
+}
+
+type keyValueDBIterateServer struct {
+	grpc.ServerStream
+}
+
+func (x *keyValueDBIterateServer) Send(m *NextResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *keyValueDBIterateServer) Recv() (*NextRequest, error) {
+	m := new(NextRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func RegisterKeyValueDBServer(s *grpc.Server, srv KeyValueDBServer) {
+	s.RegisterService(&_KeyValueDB_serviceDesc, srv)
+}
+
+func _KeyValueDB_Iterate_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(KeyValueDBServer).Iterate(&keyValueDBIterateServer{stream})
+}
+
+var _KeyValueDB_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "remotedb.KeyValueDB",
+	HandlerType: (*KeyValueDBServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Iterate",
+			Handler:       _KeyValueDB_Iterate_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "remotedb.proto",
+}
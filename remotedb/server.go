@@ -0,0 +1,106 @@
+package remotedb
+
+import (
+	"io"
+	"strings"
+
+	"github.com/cosiner/redis-migrate/migrate"
+)
+
+// defaultBatchSize is used when a NextRequest doesn't set batch_size.
+const defaultBatchSize = 256
+
+type server struct {
+	db migrate.KeyValueDB
+}
+
+// NewServer returns a KeyValueDBServer that streams db's contents to
+// whoever calls Iterate. The underlying iterator is opened when a
+// stream starts and is always released when the stream ends - on a
+// clean finish, an RPC error, or the client disconnecting - so a remote
+// caller can't leak the local store's iterator by going away.
+func NewServer(db migrate.KeyValueDB) KeyValueDBServer {
+	return server{db: db}
+}
+
+func (s server) Iterate(stream KeyValueDB_IterateServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+
+	var iter migrate.KeyValueIterator
+	if req.Prefix != "" {
+		if pi, ok := s.db.(migrate.PrefixIterableDB); ok {
+			iter = pi.PrefixIterator(req.Prefix)
+		} else {
+			iter = &prefixFilterIterator{KeyValueIterator: s.db.Iterator(), prefix: req.Prefix}
+		}
+	} else {
+		iter = s.db.Iterator()
+	}
+	defer iter.Close()
+
+	if err := s.sendBatch(stream, iter, batchSizeOf(req)); err != nil {
+		return err
+	}
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.sendBatch(stream, iter, batchSizeOf(req)); err != nil {
+			return err
+		}
+	}
+}
+
+// prefixFilterIterator restricts an underlying KeyValueIterator to keys
+// starting with prefix, for a db that doesn't implement
+// migrate.PrefixIterableDB. It mirrors the unexported iterator of the
+// same name in the migrate package, which does the equivalent job for
+// a SourceKeyIterator.
+type prefixFilterIterator struct {
+	migrate.KeyValueIterator
+	prefix string
+}
+
+func (it *prefixFilterIterator) Next() (string, []byte, error) {
+	for {
+		key, val, err := it.KeyValueIterator.Next()
+		if err != nil || key == "" {
+			return key, val, err
+		}
+		if strings.HasPrefix(key, it.prefix) {
+			return key, val, nil
+		}
+	}
+}
+
+func batchSizeOf(req *NextRequest) int {
+	if req.BatchSize > 0 {
+		return int(req.BatchSize)
+	}
+	return defaultBatchSize
+}
+
+func (s server) sendBatch(stream KeyValueDB_IterateServer, iter migrate.KeyValueIterator, batchSize int) error {
+	items := make([]*KV, 0, batchSize)
+	done := false
+	for len(items) < batchSize {
+		key, val, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		if key == "" {
+			done = true
+			break
+		}
+		items = append(items, &KV{Key: key, Value: val})
+	}
+	return stream.Send(&NextResponse{Items: items, Done: done})
+}